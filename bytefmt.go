@@ -0,0 +1,22 @@
+package main
+
+import "fmt"
+
+// byteUnits holds the binary (1024-based) unit suffixes above bytes, in ascending order
+var byteUnits = [...]string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+
+// formatBytes renders n as a human-readable size using binary units, e.g. 1536 -> "1.5 KiB"
+func formatBytes(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	value := float64(n)
+	unit := -1
+	for value >= 1024 && unit < len(byteUnits)-1 {
+		value /= 1024
+		unit++
+	}
+
+	return fmt.Sprintf("%.1f %s", value, byteUnits[unit])
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestFormatBytes(t *testing.T) {
+	cases := []struct {
+		in   int64
+		want string
+	}{
+		{0, "0 B"},
+		{500, "500 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{1024 * 1024, "1.0 MiB"},
+		{2 * 1024 * 1024, "2.0 MiB"},
+		{3 * 1024 * 1024 * 1024, "3.0 GiB"},
+	}
+
+	for _, c := range cases {
+		got := formatBytes(c.in)
+		if got != c.want {
+			t.Errorf("formatBytes(%d) == %q, want %q", c.in, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+const (
+	// partFileSuffix is appended to the target filename while a download is in progress
+	partFileSuffix = ".part"
+	// stateFileSuffix is appended to the target filename for the sidecar resume-state file
+	stateFileSuffix = ".gowget-state"
+)
+
+// downloadState is persisted alongside a .part file so an interrupted download can be resumed:
+// it lets a restart confirm the remote resource hasn't changed before appending to the partial file
+type downloadState struct {
+	URL          string
+	ETag         string
+	LastModified string
+	// Segmented records whether the .part file was written by a multi-connection segmented
+	// download, which writes ranges directly into the file via WriteAt: if a non-final segment
+	// fails while a later one still succeeds, the file's length alone can't tell a real hole in
+	// the middle from a clean, fully-written prefix, so such a file must never be resumed
+	Segmented bool
+}
+
+// loadDownloadState reads and decodes the state file at path. ok is false if the file doesn't
+// exist or can't be decoded, in which case the download should be restarted from scratch
+func loadDownloadState(path string) (state downloadState, ok bool) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return downloadState{}, false
+	}
+
+	if err = json.Unmarshal(data, &state); err != nil {
+		return downloadState{}, false
+	}
+
+	return state, true
+}
+
+// saveDownloadState writes state to path as JSON
+func saveDownloadState(path string, state downloadState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// removeDownloadState removes the state file at path, ignoring a not-exist error
+func removeDownloadState(path string) error {
+	err := os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
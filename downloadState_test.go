@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDownloadUrlResumesPartialFile(t *testing.T) {
+	tmpdir, oldPwd, err := chdirTmp()
+	if err != nil {
+		t.Errorf("chdirTmp: %q", err.Error())
+	}
+	defer restoreWorkingDir(tmpdir, oldPwd)
+
+	data := []byte("0123456789abcdefghij")
+	modTime := time.Unix(1600000000, 0)
+	var gotRange string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotRange = r.Header.Get("Range")
+		http.ServeContent(w, r, "file.bin", modTime, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/test.out"
+
+	// simulate a previous, interrupted attempt: half the file already on disk plus matching state
+	if err = ioutil.WriteFile("test.out.part", data[:10], 0644); err != nil {
+		t.Fatalf("WriteFile: %s", err.Error())
+	}
+
+	d := NewDownloader()
+	d.printer = &mockPrinter{}
+	info, err := d.webGetter.Head(url)
+	if err != nil {
+		t.Fatalf("Head: %s", err.Error())
+	}
+	if err = saveDownloadState("test.out"+stateFileSuffix, downloadState{URL: url, ETag: info.ETag, LastModified: info.LastModified}); err != nil {
+		t.Fatalf("saveDownloadState: %s", err.Error())
+	}
+
+	isFinishedChannel := make(chan bool, 1)
+	go d.downloadUrl(url, isFinishedChannel, nil)
+	ok := <-isFinishedChannel
+	if !ok {
+		t.Errorf("downloadUrl reported failure")
+	}
+
+	if gotRange != "bytes=10-" {
+		t.Errorf("server received Range header %q, want a ranged request for the missing suffix", gotRange)
+	}
+
+	downloaded, err := ioutil.ReadFile(filepath.Join(tmpdir, "test.out"))
+	if err != nil {
+		t.Fatalf("ReadFile: %s", err.Error())
+	}
+	if string(downloaded) != string(data) {
+		t.Errorf("downloaded == %q, want %q", downloaded, data)
+	}
+
+	if _, err = os.Stat("test.out" + stateFileSuffix); !os.IsNotExist(err) {
+		t.Errorf("state file should have been removed after a successful download")
+	}
+}
+
+// TestDownloadUrlDoesNotResumeWithoutValidators covers a server that sends neither an ETag nor a
+// Last-Modified header: with no validator to compare, a stale .part file must not be trusted and
+// appended to, since it could belong to a different resource entirely
+func TestDownloadUrlDoesNotResumeWithoutValidators(t *testing.T) {
+	tmpdir, oldPwd, err := chdirTmp()
+	if err != nil {
+		t.Errorf("chdirTmp: %q", err.Error())
+	}
+	defer restoreWorkingDir(tmpdir, oldPwd)
+
+	data := []byte("0123456789abcdefghij")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// no ETag, no Last-Modified (zero time.Time omits the header)
+		http.ServeContent(w, r, "file.bin", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/test.out"
+
+	// a stale .part file left over from a previous, unrelated resource
+	stale := []byte("STALEBYTES")
+	if err = ioutil.WriteFile("test.out.part", stale, 0644); err != nil {
+		t.Fatalf("WriteFile: %s", err.Error())
+	}
+	if err = saveDownloadState("test.out"+stateFileSuffix, downloadState{URL: url}); err != nil {
+		t.Fatalf("saveDownloadState: %s", err.Error())
+	}
+
+	d := NewDownloader()
+	d.printer = &mockPrinter{}
+
+	isFinishedChannel := make(chan bool, 1)
+	go d.downloadUrl(url, isFinishedChannel, nil)
+	ok := <-isFinishedChannel
+	if !ok {
+		t.Errorf("downloadUrl reported failure")
+	}
+
+	downloaded, err := ioutil.ReadFile(filepath.Join(tmpdir, "test.out"))
+	if err != nil {
+		t.Fatalf("ReadFile: %s", err.Error())
+	}
+	if string(downloaded) != string(data) {
+		t.Errorf("downloaded == %q, want a fresh full download %q, not the stale bytes spliced in", downloaded, data)
+	}
+}
+
+// TestDownloadUrlDoesNotResumeSegmentedPartFile covers a .part file left behind by a segmented run
+// that failed partway through: WriteAt can leave the file's length past a hole that was never
+// actually written, so even with validators that match, such a file must be redownloaded from
+// scratch rather than trusted and appended to
+func TestDownloadUrlDoesNotResumeSegmentedPartFile(t *testing.T) {
+	tmpdir, oldPwd, err := chdirTmp()
+	if err != nil {
+		t.Errorf("chdirTmp: %q", err.Error())
+	}
+	defer restoreWorkingDir(tmpdir, oldPwd)
+
+	data := []byte("0123456789abcdefghij")
+	modTime := time.Unix(1600000000, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file.bin", modTime, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	url := server.URL + "/test.out"
+
+	d := NewDownloader()
+	d.printer = &mockPrinter{}
+	info, err := d.webGetter.Head(url)
+	if err != nil {
+		t.Fatalf("Head: %s", err.Error())
+	}
+
+	// a .part file left by a segmented run where segment [0,5) landed, segment [5,10) failed, and
+	// segment [10,15) still landed via WriteAt past the hole, leaving the file's length (15) short
+	// of the real content length (20) with a real hole in the middle rather than just at the end
+	partFile, err := os.OpenFile("test.out.part", os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile: %s", err.Error())
+	}
+	if _, err = partFile.WriteAt(data[0:5], 0); err != nil {
+		t.Fatalf("WriteAt: %s", err.Error())
+	}
+	if _, err = partFile.WriteAt(data[10:15], 10); err != nil {
+		t.Fatalf("WriteAt: %s", err.Error())
+	}
+	if err = partFile.Close(); err != nil {
+		t.Fatalf("Close: %s", err.Error())
+	}
+	if err = saveDownloadState("test.out"+stateFileSuffix, downloadState{
+		URL: url, ETag: info.ETag, LastModified: info.LastModified, Segmented: true,
+	}); err != nil {
+		t.Fatalf("saveDownloadState: %s", err.Error())
+	}
+
+	isFinishedChannel := make(chan bool, 1)
+	go d.downloadUrl(url, isFinishedChannel, nil)
+	ok := <-isFinishedChannel
+	if !ok {
+		t.Errorf("downloadUrl reported failure")
+	}
+
+	downloaded, err := ioutil.ReadFile(filepath.Join(tmpdir, "test.out"))
+	if err != nil {
+		t.Fatalf("ReadFile: %s", err.Error())
+	}
+	if string(downloaded) != string(data) {
+		t.Errorf("downloaded == %q, want a fresh full download %q, not the hole preserved", downloaded, data)
+	}
+}
@@ -3,10 +3,10 @@ package main
 import (
 	"fmt"
 	"io"
-	"io/ioutil"
+	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -21,8 +21,10 @@ const (
 	filenameSubstitution = "_"
 	// defaultFilename is a default filename if filename could not be extracted from URL
 	defaultFilename = "index.html"
-	// tableUpdateInterval is an interval between percentage table updates
+	// tableUpdateInterval is an interval between progress reporter refreshes
 	tableUpdateInterval = 1 * time.Second
+	// segmentedDownloadThreshold is the minimum content length, in bytes, worth splitting into multiple connections
+	segmentedDownloadThreshold = 1024 * 1024
 )
 
 // printer provides way to intercept output of Downloader when needed
@@ -37,147 +39,250 @@ type printer interface {
 type webGetter interface {
 	// Get returns data body and content length by URL
 	Get(url string) (body io.ReadCloser, contentLen int, err error)
+	// Head returns the resource metadata for url
+	Head(url string) (info headInfo, err error)
+	// GetRange returns data body for url starting at byte start, up to and including end when end >= 0,
+	// or to the end of the resource when end < 0
+	GetRange(url string, start, end int64) (body io.ReadCloser, err error)
 }
 
 // Downloader downloads files by specified http urls
 type Downloader struct {
-	// downloadPercentages stores downloaded percentage for every URL
-	downloadPercentages map[string]int
-	// Percentages provides sync to prevent concurrent read/write to downloadPercentages map
-	percentageMutex sync.RWMutex
-	// statusTableRowFormat stores prepared printf format for percentages
-	statusTableRowFormat string
 	// printer provides output
 	printer printer
 	// provides data stream by URL
 	webGetter webGetter
+	// Connections is a number of concurrent connections to use per URL when the server supports byte ranges
+	Connections int
+	// NoBars forces the plain percentage-per-line progress output even when stdout is a terminal
+	NoBars bool
+	// Recursive enables following links discovered in downloaded HTML pages
+	Recursive bool
+	// MaxDepth is how many link hops a recursive download will follow from the initial URLs
+	MaxDepth int
+	// SameHost restricts a recursive download to links on the same host as the page that linked to them
+	SameHost bool
+	// progress reports download progress, keyed by destination filename; lazily selected on first use
+	progress     progress
+	progressOnce sync.Once
+	// visited records every URL enqueued during a recursive download, so link cycles terminate
+	visited   map[string]bool
+	visitedMu sync.Mutex
 }
 
 // NewDownloader returns instance of Wget
 func NewDownloader() Downloader {
 	return Downloader{
-		downloadPercentages:  map[string]int{},
-		percentageMutex:      sync.RWMutex{},
-		statusTableRowFormat: "",
-		printer:              stdPrinter{},
-		webGetter:            httpWebGetter{},
+		printer:     stdPrinter{},
+		webGetter:   httpWebGetter{},
+		Connections: 1,
+		MaxDepth:    5,
+		SameHost:    true,
 	}
 }
 
-// Download downloads files from specified urls
+// Download downloads files from specified urls, following links discovered in HTML responses when
+// Recursive is enabled
 func (d *Downloader) Download(urls []string) {
-	d.downloadPercentages = map[string]int{}
-	d.percentageMutex = sync.RWMutex{}
+	d.visited = map[string]bool{}
 
-	// remove duplicated urls
-	urlsMap := map[string]bool{}
-	uniqUrls := []string{}
+	var wg sync.WaitGroup
 	for _, url := range urls {
-		if !urlsMap[url] {
-			uniqUrls = append(uniqUrls, url)
-		}
-		urlsMap[url] = true
+		d.enqueue(url, 0, &wg)
 	}
+	wg.Wait()
+}
 
-	d.initializeStatusTable(uniqUrls)
+// enqueue starts a download of url at depth in its own goroutine tracked by wg, skipping urls
+// already seen during this Download call so link cycles terminate
+func (d *Downloader) enqueue(url string, depth int, wg *sync.WaitGroup) {
+	d.visitedMu.Lock()
+	alreadyVisited := d.visited[url]
+	d.visited[url] = true
+	d.visitedMu.Unlock()
 
-	// start downloading
-	isFinishedChannels := make([]chan bool, len(uniqUrls))
-	for i, url := range uniqUrls {
-		isFinishedChannels[i] = make(chan bool, 1)
-		go d.downloadUrl(url, isFinishedChannels[i])
+	if alreadyVisited {
+		return
 	}
 
-	// wait for downloading finishes, print percentage table row every second
-	finishedDownloads := 0
-	for finishedDownloads < len(uniqUrls) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
 
-		time.Sleep(tableUpdateInterval)
-		for _, c := range isFinishedChannels {
-			select {
-			case <-c:
-				finishedDownloads++
-			default:
-				continue
+		finished := make(chan bool, 1)
+		d.downloadUrl(url, finished, func(finalURL, filename, contentType string) {
+			if !d.Recursive || depth >= d.MaxDepth || !strings.HasPrefix(contentType, "text/html") {
+				return
 			}
+			d.crawl(finalURL, filename, depth, wg)
+		})
+		<-finished
+	}()
+}
+
+// crawl extracts links from the downloaded HTML file at filename, resolves them against baseURL,
+// filters them by SameHost and enqueues the ones worth following at depth+1
+func (d *Downloader) crawl(baseURL, filename string, depth int, wg *sync.WaitGroup) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return
+	}
+
+	links, err := extractLinks(filename, baseURL)
+	if err != nil {
+		return
+	}
+
+	for _, link := range links {
+		if d.SameHost && link.Host != base.Host {
+			continue
 		}
-		d.printStatusTableRow(uniqUrls)
+		d.enqueue(link.String(), depth+1, wg)
 	}
 }
 
-// initializeStatusTable calculates format for rows according to filename widths and prints header with filenames
-func (d *Downloader) initializeStatusTable(urls []string) {
-	filenames := make([]interface{}, len(urls))
-	d.statusTableRowFormat = ""
-	for i, url := range urls {
-		filename := d.getUniqueFilename(d.getFilename(url))
-		filenames[i] = filename
-
-		// if filename not longer then 4 chars, use percentage width 3 (3 digits and '%' sign has total width 4)
-		// leave width 3 due to percentage cell has width 4
-		width := "3"
-		if len([]rune(filename)) > 4 {
-			// cell width is width modifier + 1 due to '%' sign
-			width = strconv.Itoa(len([]rune(filename)) - 1)
+// getProgress returns the active progress reporter, choosing terminalProgress when stdout is a
+// terminal and bars haven't been disabled, or stdProgress otherwise
+func (d *Downloader) getProgress() progress {
+	d.progressOnce.Do(func() {
+		if !d.NoBars && isTerminal(os.Stdout) {
+			d.progress = newTerminalProgress(d.printer)
+		} else {
+			d.progress = newStdProgress(d.printer)
+		}
+	})
+	return d.progress
+}
+
+// Download downloads files from specified urls
+func (d *Downloader) downloadUrl(url string, finished chan bool, onComplete func(finalURL, filename, contentType string)) {
+	// preflight HEAD: lets us size the progress bar up front even for chunked responses, prefer a
+	// server-suggested filename, and follow redirects once instead of on every subsequent request
+	info, headErr := d.webGetter.Head(url)
+
+	requestURL := url
+	base := d.getFilename(url)
+	if headErr == nil {
+		if info.URL != "" {
+			requestURL = info.URL
+		}
+		if info.Filename != "" {
+			base = sanitizeFilename(info.Filename)
 		}
-		d.statusTableRowFormat += "%" + width + "d%% "
 	}
 
-	d.statusTableRowFormat += "\n"
+	if d.Recursive {
+		base = d.mirrorFilename(requestURL, base)
+	}
 
-	// use 4 as the minimum width of filename cell
-	d.printer.Printf(strings.Repeat("%4s ", len(filenames))+"\n", filenames...)
-}
+	filename := d.getUniqueFilename(base)
+	partFilename := filename + partFileSuffix
+	stateFilename := filename + stateFileSuffix
 
-// printStatusTableRow prints percentage row
-func (d *Downloader) printStatusTableRow(urls []string) {
-	percentages := d.GetDownloadPercentages()
-	percentagesSlice := make([]interface{}, len(percentages))
-	for i, url := range urls {
-		percentagesSlice[i] = percentages[url]
+	// wouldSegment is whether a fresh (non-resumed) attempt would split the download across
+	// multiple connections: the server supports ranges and the content is large enough to bother
+	wouldSegment := d.Connections > 1 && headErr == nil &&
+		info.AcceptRanges && info.ContentLength >= segmentedDownloadThreshold
+
+	file, startOffset, remoteSize, resuming := d.openPartFile(requestURL, info, headErr, partFilename, stateFilename, wouldSegment)
+	if file == nil {
+		finished <- false
+		return
 	}
-	d.printer.Printf(d.statusTableRowFormat, percentagesSlice...)
-}
+	defer file.Close()
 
-// Download downloads files from specified urls
-func (d *Downloader) downloadUrl(url string, finished chan bool) {
-	// initialize percentage
-	d.addDownloadPercentage(url, 0)
+	// a resumed download always continues over a single connection, whether or not this attempt
+	// would otherwise have segmented it, since openPartFile never resumes a segmented .part file
+	useSegments := wouldSegment && !resuming
 
-	filename := d.getUniqueFilename(d.getFilename(url))
-	tmpfile, err := ioutil.TempFile("./", filename)
-	if err != nil {
-		d.printer.ErrPrintf("Unable to create temporary file %s: %s\n", tmpfile, err.Error())
+	progress := d.getProgress()
+	var contentLen, totalCopied int
+	var err error
+
+	switch {
+	case resuming:
+		var body io.ReadCloser
+		if body, err = d.webGetter.GetRange(requestURL, startOffset, -1); err != nil {
+			d.printer.ErrPrintf("Unable to download URL %s: %s\n", url, err.Error())
+			finished <- false
+			return
+		}
+		defer body.Close()
+
+		contentLen = int(remoteSize)
+		progress.Start(filename, int64(contentLen))
+		progress.Add(filename, startOffset)
+		totalCopied = int(startOffset) + copyWithProgress(file, body, progress, filename)
+
+	case useSegments:
+		contentLen = int(info.ContentLength)
+		progress.Start(filename, int64(contentLen))
+
+		// write segments straight into file via WriteAt as they land, instead of reassembling them
+		// into a second buffer file first, so the progress counter advances live
+		segmented := segmentedWebGetter{inner: d.webGetter, connections: d.Connections}
+		if err = segmented.fetchToFile(requestURL, file, progress, filename, info.ContentLength); err != nil {
+			d.printer.ErrPrintf("Unable to download URL %s: %s\n", url, err.Error())
+			finished <- false
+			return
+		}
+		totalCopied = contentLen
+
+	default:
+		var body io.ReadCloser
+		if body, contentLen, err = d.webGetter.Get(requestURL); err != nil {
+			d.printer.ErrPrintf("Unable to download URL %s: %s\n", url, err.Error())
+			finished <- false
+			return
+		}
+		defer body.Close()
+
+		if headErr == nil && info.ContentLength > 0 {
+			// prefer the HEAD-reported size: a chunked GET response carries no Content-Length
+			contentLen = int(info.ContentLength)
+		}
+		progress.Start(filename, int64(contentLen))
+		totalCopied = copyWithProgress(file, body, progress, filename)
+	}
+
+	if contentLen > 0 && totalCopied != contentLen {
+		// leave the .part file and state in place so the download can be resumed later
 		finished <- false
 		return
 	}
-	defer tmpfile.Close()
 
-	// download
-	body, contentLen, err := d.webGetter.Get(url)
-	if err != nil {
-		d.printer.ErrPrintf("Unable to download URL %s: %s\n", url, err.Error())
+	// rename
+	if err = os.Rename(partFilename, filename); err != nil {
+		d.printer.ErrPrintf("Unable to rename %s to %s: %s", partFilename, filename, err.Error())
 		finished <- false
 		return
 	}
-	defer body.Close()
+	removeDownloadState(stateFilename)
+	progress.Finish(filename)
 
-	if contentLen == 0 {
-		d.addDownloadPercentage(url, 100)
+	if onComplete != nil {
+		onComplete(requestURL, filename, info.ContentType)
 	}
+
+	finished <- true
+	return
+}
+
+// copyWithProgress copies src into dst in adaptively-sized chunks, reporting each chunk copied to
+// prog under key as it lands, and ramping the chunk size to land around chunksPerSecond reads per
+// second once the transfer's speed is known. It returns the number of bytes copied; any error,
+// including io.EOF, simply ends the copy
+func copyWithProgress(dst io.Writer, src io.Reader, prog progress, key string) int {
 	chunkLen := initialChunkSize
-	totalCopied := 0
+	var totalCopied int
 	var lastCopied float64
 	measurementStart := time.Now()
-	for err = nil; err == nil; {
+	for err := error(nil); err == nil; {
 		var n int64
-		n, err = io.CopyN(tmpfile, body, int64(chunkLen))
+		n, err = io.CopyN(dst, src, int64(chunkLen))
 		totalCopied += int(n)
 		lastCopied += float64(n)
-
-		if contentLen > 0 {
-			d.addDownloadPercentage(url, (totalCopied*100)/contentLen)
-		}
+		prog.Add(key, n)
 
 		// correct chunkLen according to downloading speed
 		if time.Now().Sub(measurementStart) > 1*time.Second {
@@ -189,18 +294,70 @@ func (d *Downloader) downloadUrl(url string, finished chan bool) {
 			measurementStart = time.Now()
 		}
 	}
+	return totalCopied
+}
+
+// mirrorFilename lays out base on disk mirroring the URL path, as host/path/to/base, creating any
+// missing directories; getUniqueFilename is left to disambiguate only once this path is already taken
+func (d *Downloader) mirrorFilename(rawURL, base string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return base
+	}
 
-	// rename
-	err = os.Rename(tmpfile.Name(), filename)
+	dir := filepath.Join(parsed.Host, filepath.Dir(parsed.Path))
+	dir = filepath.Clean(string(filepath.Separator) + dir)[1:]
+	if dir == "." || dir == "" {
+		dir = parsed.Host
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return base
+	}
+
+	return filepath.Join(dir, base)
+}
+
+// openPartFile decides whether url's download can resume from an existing partFilename and opens
+// it accordingly: appending to the existing file when the sidecar state matches info (the result of
+// a HEAD request already made by the caller), the file isn't already complete, and the file wasn't
+// produced by a segmented run, or truncating/creating it otherwise. It returns a nil file if the
+// file could not be opened at all. remoteSize is the total size reported by HEAD, when available.
+// segmented records, in the freshly-created state, whether this attempt will itself use multiple
+// connections, so a future run knows not to trust this file's length if it gets interrupted
+func (d *Downloader) openPartFile(url string, info headInfo, headErr error, partFilename, stateFilename string, segmented bool) (file *os.File, startOffset, remoteSize int64, resuming bool) {
+	if headErr == nil {
+		state, ok := loadDownloadState(stateFilename)
+		// require at least one non-empty validator to agree: if the server sends neither an ETag nor a
+		// Last-Modified header, we have no way to tell the resource apart from a different one at the
+		// same URL, so resuming would risk silently splicing together bytes from two different responses
+		validatorsMatch := (info.ETag != "" && info.ETag == state.ETag) ||
+			(info.LastModified != "" && info.LastModified == state.LastModified)
+		// never resume a .part file a segmented run produced: WriteAt only advances the file's
+		// apparent length to the highest offset written, so a failed non-final segment can leave a
+		// hole well before that length, which a plain size comparison can't detect
+		if ok && state.URL == url && info.AcceptRanges && validatorsMatch && !state.Segmented {
+			if fi, err := os.Stat(partFilename); err == nil && fi.Size() < info.ContentLength {
+				if f, err := os.OpenFile(partFilename, os.O_APPEND|os.O_WRONLY, 0644); err == nil {
+					return f, fi.Size(), info.ContentLength, true
+				}
+			}
+		}
+	}
+
+	file, err := os.OpenFile(partFilename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
 	if err != nil {
-		d.printer.ErrPrintf("Unable to rename %s to %s: %s", tmpfile.Name(), filename, err.Error())
-		finished <- false
-		return
+		d.printer.ErrPrintf("Unable to create temporary file %s: %s\n", partFilename, err.Error())
+		return nil, 0, 0, false
 	}
 
-	// if actually copied bytes equal to contentLen, file downloaded successfully
-	finished <- (totalCopied == contentLen)
-	return
+	if headErr == nil {
+		saveDownloadState(stateFilename, downloadState{URL: url, ETag: info.ETag, LastModified: info.LastModified, Segmented: segmented})
+	} else {
+		removeDownloadState(stateFilename)
+	}
+
+	return file, 0, info.ContentLength, false
 }
 
 // getUniqueFilename returns unique filename using filename.N schema
@@ -224,28 +381,11 @@ func (d *Downloader) getFilename(url string) string {
 		filename = matches[1]
 	}
 
-	re := regexp.MustCompile("[^\\pL\\-.0-9]")
-	filename = re.ReplaceAllLiteralString(filename, filenameSubstitution)
-
-	return filename
-}
-
-// addDownloadPercentage adds/updates percentage value for URL
-func (d *Downloader) addDownloadPercentage(url string, percentage int) {
-	d.percentageMutex.Lock()
-	defer d.percentageMutex.Unlock()
-	d.downloadPercentages[url] = percentage
+	return sanitizeFilename(filename)
 }
 
-// GetDownloadPercentages returns copy of downloadPercentages map
-func (d *Downloader) GetDownloadPercentages() (percentages map[string]int) {
-	d.percentageMutex.RLock()
-	defer d.percentageMutex.RUnlock()
-
-	percentages = map[string]int{}
-	for i, v := range d.downloadPercentages {
-		percentages[i] = v
-	}
-
-	return percentages
+// sanitizeFilename replaces characters illegal in a filename with filenameSubstitution
+func sanitizeFilename(filename string) string {
+	re := regexp.MustCompile("[^\\pL\\-.0-9]")
+	return re.ReplaceAllLiteralString(filename, filenameSubstitution)
 }
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDownloadRecursiveMirrorsDirectoryTree(t *testing.T) {
+	tmpdir, oldPwd, err := chdirTmp()
+	if err != nil {
+		t.Errorf("chdirTmp: %q", err.Error())
+	}
+	defer restoreWorkingDir(tmpdir, oldPwd)
+
+	pages := map[string]string{
+		"/index.html": `<html><body><a href="/sub/page2.html">page2</a></body></html>`,
+		"/sub/page2.html": `<html><body>
+			<a href="/index.html">back to index, already visited</a>
+			<img src="pic.png">
+		</body></html>`,
+		"/sub/pic.png": "not really a png",
+	}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if filepath.Ext(r.URL.Path) == ".png" {
+			w.Header().Set("Content-Type", "image/png")
+		} else {
+			w.Header().Set("Content-Type", "text/html")
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("Parse: %s", err.Error())
+	}
+	host := serverURL.Host
+
+	d := NewDownloader()
+	d.printer = &mockPrinter{}
+	d.Recursive = true
+	d.Download([]string{server.URL + "/index.html"})
+
+	for relPath, want := range map[string]string{
+		filepath.Join(host, "index.html"):        pages["/index.html"],
+		filepath.Join(host, "sub", "page2.html"): pages["/sub/page2.html"],
+		filepath.Join(host, "sub", "pic.png"):    pages["/sub/pic.png"],
+	} {
+		got, err := ioutil.ReadFile(relPath)
+		if err != nil {
+			t.Errorf("ReadFile(%q): %s", relPath, err.Error())
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s == %q, want %q", relPath, got, want)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(host, "index.html.1")); !os.IsNotExist(err) {
+		t.Errorf("index.html should not have been downloaded twice")
+	}
+}
@@ -6,9 +6,13 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetFilename(t *testing.T) {
@@ -64,36 +68,6 @@ func restoreWorkingDir(tmpdir, oldPwd string) {
 	os.RemoveAll(tmpdir)
 }
 
-func TestInitializeStatusTable(t *testing.T) {
-	// change working dir to ensure that there are no existing files with target names
-	tmpdir, oldPwd, err := chdirTmp()
-	if err != nil {
-		t.Errorf("chdirTmp: %q", err.Error())
-	}
-	defer restoreWorkingDir(tmpdir, oldPwd)
-
-	testUrls := []string{
-		"http://example.com/0",
-		"http://example.com/output.dat",
-		"http://example.com/000",
-	}
-	wantedStatusTableRowFormat := "%3d%% %9d%% %3d%% \n"
-	wantedTableHeader := "   0 output.dat  000 \n"
-
-	d := NewDownloader()
-	p := &mockPrinter{}
-	d.printer = p
-	d.initializeStatusTable(testUrls)
-
-	if d.statusTableRowFormat != wantedStatusTableRowFormat {
-		t.Errorf("statusTableRowFormat == %q, want %q", d.statusTableRowFormat, wantedStatusTableRowFormat)
-	}
-
-	if p.stdout != wantedTableHeader {
-		t.Errorf("p.stdout == %q, want %q", p.stdout, wantedTableHeader)
-	}
-}
-
 func TestGetUniqueFilename(t *testing.T) {
 	tmpdir, oldPwd, err := chdirTmp()
 	if err != nil {
@@ -147,6 +121,14 @@ func (g *mockWebGetter) Close() error {
 	return nil
 }
 
+func (g *mockWebGetter) Head(url string) (info headInfo, err error) {
+	return headInfo{ContentLength: int64(len(g.data))}, nil
+}
+
+func (g *mockWebGetter) GetRange(url string, start, end int64) (body io.ReadCloser, err error) {
+	return nil, errors.New("mockWebGetter does not support ranges")
+}
+
 func (g *mockWebGetter) Read(p []byte) (n int, err error) {
 	n = copy(p, g.data[g.bytesCopied:])
 	g.bytesCopied += n
@@ -167,11 +149,12 @@ func TestDownloadUrl(t *testing.T) {
 	defer restoreWorkingDir(tmpdir, oldPwd)
 
 	d := NewDownloader()
+	d.printer = &mockPrinter{}
 	getter := NewMockWebGetter()
 	d.webGetter = getter
 
 	isFinishedChannel := make(chan bool, 1)
-	go d.downloadUrl("http://example.com/test.out", isFinishedChannel)
+	go d.downloadUrl("http://example.com/test.out", isFinishedChannel, nil)
 	<-isFinishedChannel
 
 	downloaded, err := ioutil.ReadFile("test.out")
@@ -182,3 +165,75 @@ func TestDownloadUrl(t *testing.T) {
 		t.Errorf("Downloaded data != gauge")
 	}
 }
+
+// TestDownloadUrlUsesSegmentsForLargeRangedDownloads covers a server that supports byte ranges and
+// serves content above segmentedDownloadThreshold: downloadUrl should fan the transfer out across
+// multiple connections and still land the exact bytes in the final file
+func TestDownloadUrlUsesSegmentsForLargeRangedDownloads(t *testing.T) {
+	tmpdir, oldPwd, err := chdirTmp()
+	if err != nil {
+		t.Errorf("chdirTmp: %q", err.Error())
+	}
+	defer restoreWorkingDir(tmpdir, oldPwd)
+
+	data := []byte(strings.Repeat("abcdefghij", 200000)) // 2,000,000 bytes, above segmentedDownloadThreshold
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file.bin", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+
+	d := NewDownloader()
+	d.printer = &mockPrinter{}
+	d.Connections = 4
+
+	isFinishedChannel := make(chan bool, 1)
+	go d.downloadUrl(server.URL+"/test.out", isFinishedChannel, nil)
+	ok := <-isFinishedChannel
+	if !ok {
+		t.Errorf("downloadUrl reported failure")
+	}
+
+	downloaded, err := ioutil.ReadFile(filepath.Join(tmpdir, "test.out"))
+	if err != nil {
+		t.Fatalf("ReadFile: %s", err.Error())
+	}
+	if !bytes.Equal(downloaded, data) {
+		t.Errorf("downloaded data != original data")
+	}
+}
+
+// TestDownloadUrlSucceedsWithUnknownContentLength covers a chunked/streaming response that never
+// reports a Content-Length, on either the HEAD preflight or the GET itself: totalCopied has nothing
+// to be compared against, so the download must still be considered complete and renamed
+func TestDownloadUrlSucceedsWithUnknownContentLength(t *testing.T) {
+	tmpdir, oldPwd, err := chdirTmp()
+	if err != nil {
+		t.Errorf("chdirTmp: %q", err.Error())
+	}
+	defer restoreWorkingDir(tmpdir, oldPwd)
+
+	data := []byte("streamed without a known length")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush() // force chunked transfer-encoding, suppressing Content-Length
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	d := NewDownloader()
+	d.printer = &mockPrinter{}
+
+	isFinishedChannel := make(chan bool, 1)
+	go d.downloadUrl(server.URL+"/test.out", isFinishedChannel, nil)
+	ok := <-isFinishedChannel
+	if !ok {
+		t.Errorf("downloadUrl reported failure for a complete download with unknown length")
+	}
+
+	downloaded, err := ioutil.ReadFile("test.out")
+	if err != nil {
+		t.Fatalf("ReadFile: %s", err.Error())
+	}
+	if !bytes.Equal(downloaded, data) {
+		t.Errorf("downloaded == %q, want %q", downloaded, data)
+	}
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"strconv"
 )
@@ -21,3 +23,90 @@ func (g httpWebGetter) Get(url string) (body io.ReadCloser, contentLen int, err
 
 	return response.Body, contentLen, nil
 }
+
+// headInfo holds the response metadata collected from a HEAD request
+type headInfo struct {
+	// ContentLength is the size of the resource in bytes, as reported by the server
+	ContentLength int64
+	// AcceptRanges is true when the server advertised byte-range support
+	AcceptRanges bool
+	// ETag is the resource's ETag, if any, used to detect whether it changed since a previous download
+	ETag string
+	// LastModified is the resource's Last-Modified header, if any
+	LastModified string
+	// Filename is the filename suggested by a Content-Disposition header, if any, still undecoded
+	// of any illegal filesystem characters
+	Filename string
+	// URL is the final URL after following any redirects the HEAD request encountered
+	URL string
+	// ContentType is the resource's Content-Type header, if any
+	ContentType string
+}
+
+// Head returns the resource metadata for url
+func (g httpWebGetter) Head(url string) (info headInfo, err error) {
+	response, err := http.Head(url)
+	if err != nil {
+		return headInfo{}, err
+	}
+	defer response.Body.Close()
+
+	contentLen, _ := strconv.ParseInt(response.Header.Get("Content-Length"), 10, 64)
+
+	finalURL := url
+	if response.Request != nil && response.Request.URL != nil {
+		finalURL = response.Request.URL.String()
+	}
+
+	return headInfo{
+		ContentLength: contentLen,
+		AcceptRanges:  response.Header.Get("Accept-Ranges") == "bytes",
+		ETag:          response.Header.Get("ETag"),
+		LastModified:  response.Header.Get("Last-Modified"),
+		Filename:      filenameFromContentDisposition(response.Header.Get("Content-Disposition")),
+		URL:           finalURL,
+		ContentType:   response.Header.Get("Content-Type"),
+	}, nil
+}
+
+// filenameFromContentDisposition extracts and decodes the filename parameter of a Content-Disposition
+// header value, supporting both the plain `filename=` form and the RFC 5987 `filename*=` form; it
+// returns an empty string when header is empty or carries no filename
+func filenameFromContentDisposition(header string) string {
+	if header == "" {
+		return ""
+	}
+
+	_, params, err := mime.ParseMediaType(header)
+	if err != nil {
+		return ""
+	}
+
+	return params["filename"]
+}
+
+// GetRange returns data body for url starting at byte start. When end >= 0, the range is the
+// inclusive [start, end]; when end < 0, the range is open-ended ("bytes=start-")
+func (g httpWebGetter) GetRange(url string, start, end int64) (body io.ReadCloser, err error) {
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if end >= 0 {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	} else {
+		request.Header.Set("Range", fmt.Sprintf("bytes=%d-", start))
+	}
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusPartialContent {
+		response.Body.Close()
+		return nil, fmt.Errorf("server responded with status %d instead of %d to a range request", response.StatusCode, http.StatusPartialContent)
+	}
+
+	return response.Body, nil
+}
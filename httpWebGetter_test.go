@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFilenameFromContentDisposition(t *testing.T) {
+	cases := []struct {
+		name, header, want string
+	}{
+		{"empty header", "", ""},
+		{"quoted", `attachment; filename="report 2020.pdf"`, "report 2020.pdf"},
+		{"token", `attachment; filename=report.pdf`, "report.pdf"},
+		{"rfc5987 non-ascii", `attachment; filename*=UTF-8''%e4%b8%96%e7%95%8c.txt`, "世界.txt"},
+		{"malformed", "not a content-disposition value;;;=", ""},
+	}
+
+	for _, c := range cases {
+		got := filenameFromContentDisposition(c.header)
+		if got != c.want {
+			t.Errorf("%s: filenameFromContentDisposition(%q) == %q, want %q", c.name, c.header, got, c.want)
+		}
+	}
+}
+
+func TestHttpWebGetterHeadContentDisposition(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", `attachment; filename="download.bin"`)
+		w.Header().Set("Content-Length", "42")
+	}))
+	defer server.Close()
+
+	info, err := (httpWebGetter{}).Head(server.URL)
+	if err != nil {
+		t.Fatalf("Head: %s", err.Error())
+	}
+	if info.Filename != "download.bin" {
+		t.Errorf("Filename == %q, want %q", info.Filename, "download.bin")
+	}
+	if info.ContentLength != 42 {
+		t.Errorf("ContentLength == %d, want 42", info.ContentLength)
+	}
+}
+
+func TestHttpWebGetterHeadFollowsRedirects(t *testing.T) {
+	var finalURL string
+	final := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer final.Close()
+	finalURL = final.URL
+
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, finalURL, http.StatusFound)
+	}))
+	defer redirector.Close()
+
+	info, err := (httpWebGetter{}).Head(redirector.URL)
+	if err != nil {
+		t.Fatalf("Head: %s", err.Error())
+	}
+	if info.URL != finalURL {
+		t.Errorf("URL == %q, want %q", info.URL, finalURL)
+	}
+}
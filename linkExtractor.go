@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/url"
+	"os"
+
+	"golang.org/x/net/html"
+)
+
+// linkAttrByTag maps each tag extractLinks looks at to the attribute that carries its URL
+var linkAttrByTag = map[string]string{
+	"a":      "href",
+	"link":   "href",
+	"img":    "src",
+	"script": "src",
+}
+
+// extractLinks parses the HTML file at path and returns every href/src found on an <a>, <link>,
+// <img> or <script> tag, resolved against base. html.Parse walks a real DOM, so comments, CDATA
+// and attributes split across a line break are handled the same way a browser would handle them
+func extractLinks(path, base string) ([]*url.URL, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := html.Parse(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var links []*url.URL
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if attrName, ok := linkAttrByTag[n.Data]; ok {
+				for _, a := range n.Attr {
+					if a.Key != attrName || a.Val == "" {
+						continue
+					}
+					if ref, err := url.Parse(a.Val); err == nil {
+						links = append(links, baseURL.ResolveReference(ref))
+					}
+					break
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	return links, nil
+}
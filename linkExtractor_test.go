@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestExtractLinks(t *testing.T) {
+	html := `<html><body>
+		<a href="/page2.html">page2</a>
+		<link rel="stylesheet" href='style.css'>
+		<img src=image.png>
+		<script src="../js/app.js"></script>
+	</body></html>`
+
+	f, err := ioutil.TempFile("", "gowgettest_")
+	if err != nil {
+		t.Fatalf("TempFile: %s", err.Error())
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.WriteString(html); err != nil {
+		t.Fatalf("WriteString: %s", err.Error())
+	}
+	f.Close()
+
+	links, err := extractLinks(f.Name(), "http://example.com/dir/page1.html")
+	if err != nil {
+		t.Fatalf("extractLinks: %s", err.Error())
+	}
+
+	want := []string{
+		"http://example.com/page2.html",
+		"http://example.com/dir/style.css",
+		"http://example.com/dir/image.png",
+		"http://example.com/js/app.js",
+	}
+
+	if len(links) != len(want) {
+		t.Fatalf("extractLinks returned %d links, want %d: %v", len(links), len(want), links)
+	}
+	for i, link := range links {
+		if link.String() != want[i] {
+			t.Errorf("links[%d] == %q, want %q", i, link.String(), want[i])
+		}
+	}
+}
+
+func TestExtractLinksIgnoresCommentedOutLinks(t *testing.T) {
+	html := `<html><body>
+		<!-- <a href="/disabled.html">disabled</a> -->
+		<a href="/live.html">live</a>
+	</body></html>`
+
+	f, err := ioutil.TempFile("", "gowgettest_")
+	if err != nil {
+		t.Fatalf("TempFile: %s", err.Error())
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.WriteString(html); err != nil {
+		t.Fatalf("WriteString: %s", err.Error())
+	}
+	f.Close()
+
+	links, err := extractLinks(f.Name(), "http://example.com/")
+	if err != nil {
+		t.Fatalf("extractLinks: %s", err.Error())
+	}
+
+	if len(links) != 1 || links[0].String() != "http://example.com/live.html" {
+		t.Errorf("extractLinks == %v, want only [http://example.com/live.html]", links)
+	}
+}
@@ -1,18 +1,26 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 )
 
 func main() {
-	urls := os.Args[1:]
+	noBars := flag.Bool("no-bars", false, "print a plain progress line per file instead of live progress bars")
+	recursive := flag.Bool("r", false, "recursively follow links in downloaded HTML pages")
+	connections := flag.Int("n", 1, "number of concurrent connections to use per URL when the server supports byte ranges")
+	flag.Parse()
 
+	urls := flag.Args()
 	if len(urls) == 0 {
 		fmt.Printf("gowget: missing url\nUsage: %s [URL]...\n", os.Args[0])
 		os.Exit(1)
 	}
 
 	downloader := NewDownloader()
+	downloader.NoBars = *noBars
+	downloader.Recursive = *recursive
+	downloader.Connections = *connections
 	downloader.Download(urls)
 }
@@ -0,0 +1,13 @@
+package main
+
+// progress reports download progress for a set of keys, each representing one in-flight download
+// (Downloader uses the destination filename as the key). Implementations decide how to render that
+// into output - as live-updating bars, or as plain log lines
+type progress interface {
+	// Start registers a new download of total bytes under key and begins reporting its progress
+	Start(key string, total int64)
+	// Add reports that n more bytes have been downloaded for key
+	Add(key string, n int64)
+	// Finish marks key's download as complete
+	Finish(key string)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// segmentedWebGetter downloads a single URL over multiple concurrent range requests, writing each
+// segment directly into the destination file via WriteAt as it arrives, rather than reassembling
+// into a second buffer file first. Callers decide whether a transfer is worth segmenting (based on
+// AcceptRanges and size) before constructing one; segmentedWebGetter always splits into connections
+// pieces
+type segmentedWebGetter struct {
+	// inner is used to perform the ranged GETs
+	inner webGetter
+	// connections is the number of segments to split the download into
+	connections int
+}
+
+// Get delegates to inner: segmented transfers are driven through fetchToFile instead, which needs
+// direct access to the destination file to write segments in place as they land
+func (g segmentedWebGetter) Get(url string) (body io.ReadCloser, contentLen int, err error) {
+	return g.inner.Get(url)
+}
+
+// Head delegates to inner
+func (g segmentedWebGetter) Head(url string) (info headInfo, err error) {
+	return g.inner.Head(url)
+}
+
+// GetRange delegates to inner
+func (g segmentedWebGetter) GetRange(url string, start, end int64) (body io.ReadCloser, err error) {
+	return g.inner.GetRange(url, start, end)
+}
+
+// fetchToFile splits size into g.connections byte ranges, downloads them concurrently via
+// inner.GetRange and writes each segment straight into file at its offset, reporting every chunk
+// written to prog under key as it lands so the progress counter advances live instead of jumping
+// from 0% to 100% once the whole transfer is done
+func (g segmentedWebGetter) fetchToFile(url string, file *os.File, prog progress, key string, size int64) error {
+	segmentSize := size / int64(g.connections)
+
+	var wg sync.WaitGroup
+	errs := make([]error, g.connections)
+
+	for i := 0; i < g.connections; i++ {
+		start := int64(i) * segmentSize
+		end := start + segmentSize - 1
+		if i == g.connections-1 {
+			end = size - 1
+		}
+
+		wg.Add(1)
+		go func(i int, start, end int64) {
+			defer wg.Done()
+			errs[i] = g.fetchSegment(url, file, start, end, prog, key)
+		}(i, start, end)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fetchSegment downloads the inclusive byte range [start, end] and writes it into file at offset
+// start, reporting each chunk written to prog under key as it lands
+func (g segmentedWebGetter) fetchSegment(url string, file *os.File, start, end int64, prog progress, key string) error {
+	body, err := g.inner.GetRange(url, start, end)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	w := &progressOffsetWriter{offsetWriter: offsetWriter{file: file, offset: start}, progress: prog, key: key}
+	_, err = io.Copy(w, body)
+	return err
+}
+
+// offsetWriter writes sequentially to file starting at offset, advancing offset after each write
+type offsetWriter struct {
+	file   *os.File
+	offset int64
+}
+
+func (w *offsetWriter) Write(p []byte) (n int, err error) {
+	n, err = w.file.WriteAt(p, w.offset)
+	w.offset += int64(n)
+	return n, err
+}
+
+// progressOffsetWriter wraps offsetWriter, reporting every successful write to progress under key,
+// so concurrent segment downloads feed the same live counter a single-stream download would
+type progressOffsetWriter struct {
+	offsetWriter
+	progress progress
+	key      string
+}
+
+func (w *progressOffsetWriter) Write(p []byte) (n int, err error) {
+	n, err = w.offsetWriter.Write(p)
+	w.progress.Add(w.key, int64(n))
+	return n, err
+}
@@ -0,0 +1,64 @@
+package main
+
+import "sync"
+
+// stdProgress reports progress as one line per key each time its percentage changes. It's the
+// fallback used for non-terminal output (redirected to a file or pipe, or via --no-bars), where
+// redrawing a bar in place doesn't make sense
+type stdProgress struct {
+	printer printer
+
+	mu          sync.Mutex
+	totals      map[string]int64
+	done        map[string]int64
+	lastPercent map[string]int
+}
+
+// newStdProgress returns a stdProgress that writes through p
+func newStdProgress(p printer) *stdProgress {
+	return &stdProgress{
+		printer:     p,
+		totals:      map[string]int64{},
+		done:        map[string]int64{},
+		lastPercent: map[string]int{},
+	}
+}
+
+// Start registers key and prints its starting size
+func (s *stdProgress) Start(key string, total int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.totals[key] = total
+	s.done[key] = 0
+	s.lastPercent[key] = -1
+	s.printer.Printf("%s: starting, %s\n", key, formatBytes(total))
+}
+
+// Add accumulates n downloaded bytes for key and prints a line whenever the percentage advances
+func (s *stdProgress) Add(key string, n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.done[key] += n
+	total := s.totals[key]
+	if total <= 0 {
+		return
+	}
+
+	percent := int(s.done[key] * 100 / total)
+	if percent == s.lastPercent[key] {
+		return
+	}
+	s.lastPercent[key] = percent
+
+	s.printer.Printf("%s: %d%%, %s/%s\n", key, percent, formatBytes(s.done[key]), formatBytes(total))
+}
+
+// Finish prints a completion line for key
+func (s *stdProgress) Finish(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.printer.Printf("%s: done, %s\n", key, formatBytes(s.done[key]))
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// progressBarWidth is the number of characters used to draw the filled portion of a bar
+	progressBarWidth = 30
+	// speedSmoothing is the weight given to the newest bytes/sec sample in the exponential moving average
+	speedSmoothing = 0.3
+)
+
+// terminalProgress renders one progress bar per key, redrawing all of them in place on every tick
+// using an ANSI cursor-up escape, inspired by pb.Pool
+type terminalProgress struct {
+	printer printer
+
+	mu            sync.Mutex
+	order         []string
+	bars          map[string]*progressBar
+	renderedLines int
+	ticker        *time.Ticker
+	stopped       chan struct{}
+}
+
+// progressBar tracks one key's download progress and a smoothed bytes/sec estimate
+type progressBar struct {
+	total       int64
+	done        int64
+	speed       float64
+	speedInited bool
+	lastTick    time.Time
+	lastDone    int64
+	finished    bool
+}
+
+// newTerminalProgress returns a terminalProgress that writes through p
+func newTerminalProgress(p printer) *terminalProgress {
+	return &terminalProgress{printer: p, bars: map[string]*progressBar{}}
+}
+
+// Start registers key and starts the refresh ticker if it isn't already running
+func (t *terminalProgress) Start(key string, total int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.bars[key] = &progressBar{total: total, lastTick: time.Now()}
+	t.order = append(t.order, key)
+
+	if t.ticker == nil {
+		t.ticker = time.NewTicker(tableUpdateInterval)
+		t.stopped = make(chan struct{})
+		go t.refreshLoop(t.ticker, t.stopped)
+	}
+}
+
+// Add accumulates n downloaded bytes for key
+func (t *terminalProgress) Add(key string, n int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if bar, ok := t.bars[key]; ok {
+		bar.done += n
+	}
+}
+
+// Finish marks key as complete, redraws once more and stops the ticker once every bar is finished
+func (t *terminalProgress) Finish(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if bar, ok := t.bars[key]; ok {
+		bar.done = bar.total
+		bar.finished = true
+	}
+	t.render()
+
+	for _, k := range t.order {
+		if !t.bars[k].finished {
+			return
+		}
+	}
+	if t.ticker != nil {
+		t.ticker.Stop()
+		t.ticker = nil
+		close(t.stopped)
+	}
+}
+
+// refreshLoop redraws the bars on every tick until stopped is closed
+func (t *terminalProgress) refreshLoop(ticker *time.Ticker, stopped chan struct{}) {
+	for {
+		select {
+		case <-ticker.C:
+			t.mu.Lock()
+			t.render()
+			t.mu.Unlock()
+		case <-stopped:
+			return
+		}
+	}
+}
+
+// render redraws all bars in place; must be called with t.mu held. It moves the cursor up by
+// however many lines the previous call to render actually printed, not by the current number of
+// bars - those can differ when Start registers a new key between two ticks
+func (t *terminalProgress) render() {
+	if t.renderedLines > 0 {
+		t.printer.Printf("\033[%dA", t.renderedLines)
+	}
+
+	now := time.Now()
+	for _, key := range t.order {
+		bar := t.bars[key]
+		elapsed := now.Sub(bar.lastTick)
+		speed := bar.updateSpeed(elapsed)
+		bar.lastTick = now
+		bar.lastDone = bar.done
+
+		t.printer.Printf("%s\n", formatProgressLine(key, bar, speed))
+	}
+	t.renderedLines = len(t.order)
+}
+
+// updateSpeed folds a new bytes/sec sample (done-lastDone over elapsed) into the exponential
+// moving average and returns the updated estimate
+func (b *progressBar) updateSpeed(elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return b.speed
+	}
+
+	sample := float64(b.done-b.lastDone) / elapsed.Seconds()
+	if !b.speedInited {
+		b.speed = sample
+		b.speedInited = true
+	} else {
+		b.speed = speedSmoothing*sample + (1-speedSmoothing)*b.speed
+	}
+
+	return b.speed
+}
+
+// formatProgressLine renders one bar's line: key, a gauge, percentage, downloaded/total, speed and ETA
+func formatProgressLine(key string, b *progressBar, speed float64) string {
+	percent, filled := 0, 0
+	if b.total > 0 {
+		percent = int(b.done * 100 / b.total)
+		filled = int(int64(progressBarWidth) * b.done / b.total)
+		if filled > progressBarWidth {
+			filled = progressBarWidth
+		}
+	}
+	gauge := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+
+	return fmt.Sprintf("%-24s [%s] %3d%% %s/%s %s/s ETA %s",
+		key, gauge, percent, formatBytes(b.done), formatBytes(b.total), formatBytes(int64(speed)), formatETA(b.total-b.done, speed))
+}
+
+// formatETA estimates the remaining time at speed bytes/sec, or "--:--" when it can't be estimated
+func formatETA(remaining int64, speed float64) string {
+	if speed <= 0 || remaining <= 0 {
+		return "--:--"
+	}
+
+	return time.Duration(float64(remaining) / speed * float64(time.Second)).String()
+}
+
+// isTerminal reports whether f is attached to a terminal rather than a file, pipe or redirect
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
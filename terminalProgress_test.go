@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestProgressBarUpdateSpeed feeds a synthetic sequence of ticks into updateSpeed and checks that
+// the exponential moving average converges towards a steady bytes/sec rate
+func TestProgressBarUpdateSpeed(t *testing.T) {
+	b := &progressBar{}
+
+	ticks := []struct {
+		doneDelta int64
+		elapsed   time.Duration
+	}{
+		{1000, time.Second},
+		{1000, time.Second},
+		{1000, time.Second},
+		{1000, time.Second},
+		{1000, time.Second},
+	}
+
+	var speed float64
+	for _, tick := range ticks {
+		b.done += tick.doneDelta
+		speed = b.updateSpeed(tick.elapsed)
+		b.lastDone = b.done
+	}
+
+	// a steady 1000 B/s input should converge close to 1000 B/s
+	if speed < 950 || speed > 1000 {
+		t.Errorf("speed == %f, want within [950, 1000]", speed)
+	}
+}
+
+func TestProgressBarUpdateSpeedNoElapsed(t *testing.T) {
+	b := &progressBar{speed: 42}
+	if got := b.updateSpeed(0); got != 42 {
+		t.Errorf("updateSpeed(0) == %f, want 42", got)
+	}
+}
+
+// TestRenderCursorUpMatchesPreviouslyPrintedLines covers a bar registered between two ticks: the
+// cursor-up count on the next render must match what the previous render actually printed, not the
+// current (larger) number of bars, or it scrolls up into whatever was printed before the bars
+func TestRenderCursorUpMatchesPreviouslyPrintedLines(t *testing.T) {
+	p := &mockPrinter{}
+	tp := newTerminalProgress(p)
+	defer func() {
+		tp.Finish("first")
+		tp.Finish("second")
+	}()
+
+	tp.Start("first", 100)
+	tp.mu.Lock()
+	tp.render() // 1 bar on screen: prints 1 line, no cursor-up yet
+	tp.mu.Unlock()
+
+	p.stdout = ""
+	tp.Start("second", 100) // a second URL reaches Start before the next tick
+	tp.mu.Lock()
+	tp.render()
+	tp.mu.Unlock()
+
+	if strings.Count(p.stdout, "\n") != 2 {
+		t.Fatalf("expected 2 printed lines, got %q", p.stdout)
+	}
+	if !strings.HasPrefix(p.stdout, "\033[1A") {
+		t.Errorf("render() == %q, want it to move the cursor up by 1 (lines actually printed last time), not 2", p.stdout)
+	}
+}
+
+func TestFormatETA(t *testing.T) {
+	cases := []struct {
+		remaining int64
+		speed     float64
+		want      string
+	}{
+		{0, 100, "--:--"},
+		{100, 0, "--:--"},
+		{1000, 100, "10s"},
+		{500, 1000, "500ms"},
+	}
+
+	for _, c := range cases {
+		got := formatETA(c.remaining, c.speed)
+		if got != c.want {
+			t.Errorf("formatETA(%d, %f) == %q, want %q", c.remaining, c.speed, got, c.want)
+		}
+	}
+}